@@ -18,6 +18,7 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/gravitational/trace"
@@ -128,11 +129,13 @@ type Linear struct {
 	attempt    int64
 	closedChan chan time.Time
 	jitter     JitterFunc
+	timer      *time.Timer
 }
 
 // Reset resetes retry period to initial state
 func (r *Linear) Reset() {
 	r.attempt = 0
+	stopTimer(r.timer)
 }
 
 // Clone creates an identical copy of Linear with fresh state.
@@ -162,16 +165,240 @@ func (r *Linear) Duration() time.Duration {
 
 // After returns channel that fires with timeout
 // defined in Duration method, as a special case
-// if Duration is 0 returns a closed channel
+// if Duration is 0 returns a closed channel.  The underlying timer is
+// cached and reset on each call rather than allocated fresh, avoiding
+// the per-call allocation that time.After incurs in tight retry loops.
 func (r *Linear) After() <-chan time.Time {
 	d := r.Duration()
 	if d < 1 {
 		return r.closedChan
 	}
-	return time.After(d)
+	r.timer = resetTimer(r.timer, d)
+	return r.timer.C
 }
 
 // String returns user-friendly representation of the LinearPeriod
 func (r *Linear) String() string {
 	return fmt.Sprintf("Linear(attempt=%v, duration=%v)", r.attempt, r.Duration())
 }
+
+// resetTimer returns a *time.Timer that fires after d, reusing timer
+// when non-nil instead of allocating a new one.  time.After leaks its
+// underlying Timer until it fires, which dominates allocations in tight
+// retry loops that repeatedly call After and abandon the result.
+func resetTimer(timer *time.Timer, d time.Duration) *time.Timer {
+	if timer == nil {
+		return time.NewTimer(d)
+	}
+	stopTimer(timer)
+	timer.Reset(d)
+	return timer
+}
+
+// stopTimer stops and drains timer so that it is safe to Reset or
+// discard.  No-op if timer is nil.
+func stopTimer(timer *time.Timer) {
+	if timer == nil {
+		return
+	}
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+}
+
+// JitterMode selects how an Exponential retry randomizes the delay it
+// computes for a given attempt.
+type JitterMode string
+
+const (
+	// JitterNone applies no jitter; the delay is used as calculated.
+	JitterNone JitterMode = "none"
+	// JitterFull selects a delay uniformly distributed on [0, d).
+	JitterFull JitterMode = "full"
+	// JitterEqual selects a delay of d/2 + U[0, d/2).  This is the same
+	// jitter behavior as the default Jitter used by Linear.
+	JitterEqual JitterMode = "equal"
+	// JitterDecorrelated selects the next delay as a random value in
+	// [Base, prev*3), capped at Max, where prev is the delay chosen for
+	// the previous attempt (the first attempt uses Base).  The delay is
+	// chosen once per attempt: repeated calls to Duration before the next
+	// Inc return the same value.  This requires Duration to keep state
+	// across calls; see
+	// https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/.
+	JitterDecorrelated JitterMode = "decorrelated"
+)
+
+// ExponentialConfig sets up retry configuration using an exponential
+// progression.
+type ExponentialConfig struct {
+	// Base is the delay used for the first attempt, can't be 0.
+	Base time.Duration
+	// Max is the maximum value of the progression, can't be 0.
+	Max time.Duration
+	// Multiplier is the factor the delay is multiplied by for each
+	// subsequent attempt.  Defaults to 2 if unset.
+	Multiplier float64
+	// JitterMode selects how randomness is applied to the computed delay.
+	// Defaults to JitterEqual.
+	JitterMode JitterMode
+}
+
+// CheckAndSetDefaults checks and sets defaults
+func (c *ExponentialConfig) CheckAndSetDefaults() error {
+	if c.Base == 0 {
+		return trace.BadParameter("missing parameter Base")
+	}
+	if c.Max == 0 {
+		return trace.BadParameter("missing parameter Max")
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = 2
+	}
+	if c.JitterMode == "" {
+		c.JitterMode = JitterEqual
+	}
+	return nil
+}
+
+// NewExponential returns a new instance of exponential retry
+func NewExponential(cfg ExponentialConfig) (*Exponential, error) {
+	if err := cfg.CheckAndSetDefaults(); err != nil {
+		return nil, trace.Wrap(err)
+	}
+	return newExponential(cfg), nil
+}
+
+// newExponential creates an instance of Exponential from a
+// previously verified configuration.
+func newExponential(cfg ExponentialConfig) *Exponential {
+	closedChan := make(chan time.Time)
+	close(closedChan)
+	return &Exponential{
+		ExponentialConfig: cfg,
+		closedChan:        closedChan,
+		rng:               rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Exponential is used to calculate a retry delay that follows an
+// exponential progression, i.e. Base*Multiplier^attempt capped at Max,
+// with an optional jitter applied on top (see JitterMode).  With
+// JitterDecorrelated the delay follows that mode's own sequence instead,
+// and Multiplier is not used.
+type Exponential struct {
+	// ExponentialConfig is the exponential retry config
+	ExponentialConfig
+	attempt    int64
+	closedChan chan time.Time
+	rng        *rand.Rand
+	timer      *time.Timer
+	// prev holds the delay returned by the last computed call to
+	// decorrelated, used only by JitterDecorrelated.
+	prev time.Duration
+	// prevAttempt is the attempt prev was computed for.  decorrelated
+	// recomputes (and advances prev) only once per attempt, so repeated
+	// calls to Duration/String within the same attempt - e.g. logging a
+	// retry - don't desync the sequence from what After will use.
+	prevAttempt  int64
+	prevComputed bool
+}
+
+// Reset resets retry state, including any JitterDecorrelated state.
+func (r *Exponential) Reset() {
+	r.attempt = 0
+	r.prev = 0
+	r.prevAttempt = 0
+	r.prevComputed = false
+	stopTimer(r.timer)
+}
+
+// Clone creates an identical copy of Exponential with fresh state.
+func (r *Exponential) Clone() Retry {
+	return newExponential(r.ExponentialConfig)
+}
+
+// Inc increments attempt counter
+func (r *Exponential) Inc() {
+	r.attempt++
+}
+
+// Duration returns retry duration based on state
+func (r *Exponential) Duration() time.Duration {
+	if r.JitterMode == JitterDecorrelated {
+		return r.decorrelated()
+	}
+	d := r.Max
+	if raw := float64(r.Base) * math.Pow(r.Multiplier, float64(r.attempt)); raw > 0 && raw < float64(r.Max) {
+		d = time.Duration(raw)
+	}
+	switch r.JitterMode {
+	case JitterFull:
+		d = r.jitterFull(d)
+	case JitterEqual:
+		d = r.jitterEqual(d)
+	}
+	return d
+}
+
+// jitterFull returns a value chosen uniformly at random from [0, d).
+func (r *Exponential) jitterFull(d time.Duration) time.Duration {
+	if d < 1 {
+		return 0
+	}
+	return time.Duration(r.rng.Int63n(int64(d)))
+}
+
+// jitterEqual returns d/2 plus a value chosen uniformly at random from
+// [0, d/2).
+func (r *Exponential) jitterEqual(d time.Duration) time.Duration {
+	if d < 1 {
+		return 0
+	}
+	return (d / 2) + time.Duration(r.rng.Int63n(int64(d))/2)
+}
+
+// decorrelated implements JitterDecorrelated, selecting the next delay
+// as U[Base, prev*3) capped at Max, and persisting it as prev for the
+// following attempt.  The value is computed at most once per attempt:
+// repeated calls (e.g. Duration via String, for logging) before the
+// next Inc return the same cached value instead of advancing the
+// sequence again.
+func (r *Exponential) decorrelated() time.Duration {
+	if r.prevComputed && r.prevAttempt == r.attempt {
+		return r.prev
+	}
+	next := r.Base
+	if r.prev != 0 {
+		upper := int64(r.prev) * 3
+		next = r.Base + time.Duration(r.rng.Int63n(upper-int64(r.Base)))
+		if next > r.Max {
+			next = r.Max
+		}
+	}
+	r.prev = next
+	r.prevAttempt = r.attempt
+	r.prevComputed = true
+	return next
+}
+
+// After returns channel that fires with timeout
+// defined in Duration method, as a special case
+// if Duration is 0 returns a closed channel.  The underlying timer is
+// cached and reset on each call rather than allocated fresh; see
+// resetTimer.
+func (r *Exponential) After() <-chan time.Time {
+	d := r.Duration()
+	if d < 1 {
+		return r.closedChan
+	}
+	r.timer = resetTimer(r.timer, d)
+	return r.timer.C
+}
+
+// String returns user-friendly representation of the Exponential retry
+func (r *Exponential) String() string {
+	return fmt.Sprintf("Exponential(attempt=%v, duration=%v)", r.attempt, r.Duration())
+}
@@ -0,0 +1,154 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/gravitational/trace"
+)
+
+// decisionKind enumerates the possible outcomes of classifying an error
+// via a RetryPolicy.
+type decisionKind int
+
+const (
+	decisionKindRetry decisionKind = iota
+	decisionKindAbort
+	decisionKindRetryAfter
+)
+
+// Decision is returned by a RetryPolicy to tell Do how to proceed after
+// fn has returned an error.  Build one with DecisionRetry, DecisionAbort,
+// or DecisionRetryAfter.
+type Decision struct {
+	kind  decisionKind
+	after time.Duration
+}
+
+// DecisionRetry tells Do to retry fn, waiting on r's normal schedule
+// between attempts.
+func DecisionRetry() Decision {
+	return Decision{kind: decisionKindRetry}
+}
+
+// DecisionAbort tells Do to stop retrying and return the classified
+// error, wrapped, to the caller.
+func DecisionAbort() Decision {
+	return Decision{kind: decisionKindAbort}
+}
+
+// DecisionRetryAfter tells Do to retry fn after waiting d instead of
+// consulting r's schedule.  Useful for honoring a server-provided hint
+// such as a Retry-After header.
+func DecisionRetryAfter(d time.Duration) Decision {
+	return Decision{kind: decisionKindRetryAfter, after: d}
+}
+
+// RetryPolicy classifies an error returned by the function passed to Do,
+// deciding whether (and how) it should be retried.
+type RetryPolicy interface {
+	// Classify inspects err and returns the Decision that Do should act
+	// on.  err is never nil.
+	Classify(err error) Decision
+}
+
+// RetryPolicyFunc is an adapter allowing the use of ordinary functions as
+// a RetryPolicy.
+type RetryPolicyFunc func(err error) Decision
+
+// Classify calls f(err).
+func (f RetryPolicyFunc) Classify(err error) Decision {
+	return f(err)
+}
+
+// AlwaysRetry is a RetryPolicy that retries every error.
+var AlwaysRetry RetryPolicy = RetryPolicyFunc(func(err error) Decision {
+	return DecisionRetry()
+})
+
+// NeverRetry is a RetryPolicy that aborts on the first error.
+var NeverRetry RetryPolicy = RetryPolicyFunc(func(err error) Decision {
+	return DecisionAbort()
+})
+
+// RetryOnTemporary retries errors that self-report as temporary via the
+// standard `interface{ Temporary() bool }` (which net.Error satisfies),
+// and aborts on everything else.
+var RetryOnTemporary RetryPolicy = RetryPolicyFunc(func(err error) Decision {
+	var temp interface{ Temporary() bool }
+	if errors.As(err, &temp) && temp.Temporary() {
+		return DecisionRetry()
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Temporary() {
+		return DecisionRetry()
+	}
+	return DecisionAbort()
+})
+
+// RetryOnCodes builds a RetryPolicy that retries errors whose
+// trace.ErrorCode is one of codes, and aborts on everything else.
+func RetryOnCodes(codes ...trace.ErrorCode) RetryPolicy {
+	retryable := make(map[trace.ErrorCode]struct{}, len(codes))
+	for _, code := range codes {
+		retryable[code] = struct{}{}
+	}
+	return RetryPolicyFunc(func(err error) Decision {
+		if _, ok := retryable[trace.Code(err)]; ok {
+			return DecisionRetry()
+		}
+		return DecisionAbort()
+	})
+}
+
+// Do calls fn in a loop until it succeeds, ctx is canceled, or policy
+// decides to abort.  On each error returned by fn, policy classifies it:
+// a DecisionRetry waits on r's schedule before trying again, a
+// DecisionRetryAfter waits for the supplied duration instead, and a
+// DecisionAbort returns the wrapped error immediately.  Do replaces the
+// ad-hoc `for { <-r.After(); ... }` retry loops scattered across callers
+// with a single, testable primitive.
+func Do(ctx context.Context, r Retry, policy RetryPolicy, fn func(ctx context.Context) error) error {
+	for {
+		err := fn(ctx)
+		if err == nil {
+			return nil
+		}
+		decision := policy.Classify(err)
+		switch decision.kind {
+		case decisionKindAbort:
+			return trace.Wrap(err)
+		case decisionKindRetryAfter:
+			select {
+			case <-time.After(decision.after):
+			case <-ctx.Done():
+				return trace.Wrap(ctx.Err())
+			}
+		default:
+			r.Inc()
+			select {
+			case <-r.After():
+			case <-ctx.Done():
+				return trace.Wrap(ctx.Err())
+			}
+		}
+	}
+}
@@ -0,0 +1,128 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestDoAbortsWithoutRetrying verifies that a DecisionAbort stops Do
+// after a single call to fn and returns the wrapped error.
+func TestDoAbortsWithoutRetrying(t *testing.T) {
+	r, err := NewLinear(LinearConfig{First: time.Millisecond, Step: time.Millisecond, Max: time.Second})
+	require.NoError(t, err)
+
+	calls := 0
+	sentinel := errors.New("boom")
+
+	retErr := Do(context.Background(), r, NeverRetry, func(ctx context.Context) error {
+		calls++
+		return sentinel
+	})
+
+	require.Error(t, retErr)
+	require.Equal(t, 1, calls, "Do must not call fn again after a DecisionAbort")
+}
+
+// TestDoRetryAfterUsesGivenDuration verifies that a DecisionRetryAfter
+// waits the duration it supplies rather than consulting r's own
+// schedule, which here is far longer than the hint.
+func TestDoRetryAfterUsesGivenDuration(t *testing.T) {
+	r, err := NewLinear(LinearConfig{First: time.Hour, Step: time.Hour, Max: time.Hour})
+	require.NoError(t, err)
+
+	calls := 0
+	policy := RetryPolicyFunc(func(err error) Decision {
+		calls++
+		if calls > 1 {
+			return DecisionAbort()
+		}
+		return DecisionRetryAfter(10 * time.Millisecond)
+	})
+
+	start := time.Now()
+	retErr := Do(context.Background(), r, policy, func(ctx context.Context) error {
+		return errors.New("retry me")
+	})
+	elapsed := time.Since(start)
+
+	require.Error(t, retErr)
+	require.Equal(t, 2, calls)
+	require.Less(t, elapsed, time.Second, "Do should honor the policy's RetryAfter duration, not r's hour-long schedule")
+}
+
+// TestDoContextCancelDuringRetryAfter verifies that canceling ctx while
+// Do is waiting out a DecisionRetryAfter returns promptly with ctx's
+// error, rather than blocking for the full RetryAfter duration.
+func TestDoContextCancelDuringRetryAfter(t *testing.T) {
+	r, err := NewLinear(LinearConfig{First: time.Millisecond, Step: time.Millisecond, Max: time.Second})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicyFunc(func(err error) Decision {
+		return DecisionRetryAfter(time.Hour)
+	})
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- Do(ctx, r, policy, func(ctx context.Context) error {
+			return errors.New("retry me")
+		})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case retErr := <-errC:
+		require.Error(t, retErr)
+	case <-time.After(time.Second):
+		t.Fatal("Do did not return promptly after ctx was canceled during the RetryAfter wait")
+	}
+}
+
+// TestDoContextCancelDuringDefaultWait verifies that canceling ctx while
+// Do is waiting on r's own schedule (the DecisionRetry path) returns
+// promptly with ctx's error, rather than blocking for r's full delay.
+func TestDoContextCancelDuringDefaultWait(t *testing.T) {
+	r, err := NewLinear(LinearConfig{First: time.Hour, Step: time.Hour, Max: time.Hour})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- Do(ctx, r, AlwaysRetry, func(ctx context.Context) error {
+			return errors.New("retry me")
+		})
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+
+	select {
+	case retErr := <-errC:
+		require.Error(t, retErr)
+	case <-time.After(time.Second):
+		t.Fatal("Do did not return promptly after ctx was canceled during r's After wait")
+	}
+}
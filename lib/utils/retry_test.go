@@ -0,0 +1,85 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestExponentialDecorrelatedStringReadOnly verifies that calling String
+// (which itself calls Duration) does not advance the JitterDecorrelated
+// sequence; only Inc should move it to the next attempt.
+func TestExponentialDecorrelatedStringReadOnly(t *testing.T) {
+	r, err := NewExponential(ExponentialConfig{
+		Base:       time.Millisecond,
+		Max:        time.Second,
+		JitterMode: JitterDecorrelated,
+	})
+	require.NoError(t, err)
+
+	first := r.Duration()
+	for i := 0; i < 5; i++ {
+		_ = r.String()
+		require.Equal(t, first, r.Duration(), "logging via String must not advance the decorrelated sequence")
+	}
+
+	r.Inc()
+	require.NotEqual(t, first, r.Duration(), "Inc should be the only thing that advances the sequence")
+}
+
+// drainAfter drains a single value from r.After(), the way a retry loop's
+// `select { case <-r.After(): ... }` would.
+func drainAfter(r Retry) {
+	<-r.After()
+}
+
+// TestAfterZeroAllocations demonstrates that repeated calls to After do
+// not allocate, now that Linear and Exponential cache and reuse a single
+// *time.Timer instead of calling time.After.
+func TestAfterZeroAllocations(t *testing.T) {
+	linear := newLinear(LinearConfig{First: 1, Step: 1, Max: time.Second})
+	exponential := newExponential(ExponentialConfig{Base: 1, Max: time.Second, Multiplier: 2, JitterMode: JitterNone})
+
+	for name, r := range map[string]Retry{"Linear": linear, "Exponential": exponential} {
+		r := r
+		result := testing.Benchmark(func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				drainAfter(r)
+			}
+		})
+		require.Zerof(t, result.AllocsPerOp(), "%s.After allocated %v bytes/op over %v allocs/op", name, result.AllocedBytesPerOp(), result.AllocsPerOp())
+	}
+}
+
+func BenchmarkLinear_After(b *testing.B) {
+	r := newLinear(LinearConfig{First: 1, Step: 1, Max: time.Second})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		drainAfter(r)
+	}
+}
+
+func BenchmarkExponential_After(b *testing.B) {
+	r := newExponential(ExponentialConfig{Base: 1, Max: time.Second, Multiplier: 2, JitterMode: JitterNone})
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		drainAfter(r)
+	}
+}
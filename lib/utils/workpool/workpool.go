@@ -17,9 +17,13 @@ limitations under the License.
 package workpool
 
 import (
+	"container/heap"
 	"context"
+	"math"
 	"sync"
+	"time"
 
+	"github.com/gravitational/trace"
 	"go.uber.org/atomic"
 )
 
@@ -36,6 +40,19 @@ type Lease interface {
 	Release()
 }
 
+// LeaseWithDeadline is a Lease granted by a group configured with a TTL
+// (see Pool.SetTTL).  The group auto-releases the lease if Renew is not
+// called before the TTL elapses, which lets leases stand in for remote
+// resources (proxy tunnels, session slots) whose holders can crash
+// without ever calling Release.
+type LeaseWithDeadline interface {
+	Lease
+	// Renew pushes this lease's deadline forward by the group's
+	// currently configured TTL.  Returns an error if the lease has
+	// already expired (and therefore been released).
+	Renew() error
+}
+
 // Pool manages a collection of work groups by key and is the primary means
 // by which groups are managed.  Each work group has an adjustable target value
 // which is the number of target leases which should be active for the given
@@ -52,10 +69,39 @@ type Pool interface {
 	// Set sets the target for the specified key.
 	Set(key interface{}, target uint64)
 
+	// SetGlobal sets (or, with 0, clears) the ceiling on the sum of
+	// active leases granted across all groups in the pool.
+	SetGlobal(max uint64)
+
+	// SetTTL configures the group for key to grant LeaseWithDeadline
+	// leases that are auto-released after ttl unless renewed.  A ttl of
+	// 0 disables the behavior and reverts to ordinary, non-expiring
+	// leases.  Automatically starts the associated group, with a Target
+	// of 0, if it does not exist.
+	SetTTL(key interface{}, ttl time.Duration)
+
+	// SetRate configures the group for key to grant new leases no
+	// faster than limit allows, independent of its concurrent Target.  A
+	// zero-value RateLimit disables throttling.  Automatically starts
+	// the associated group, with a Target of 0, if it does not exist.
+	SetRate(key interface{}, limit RateLimit)
+
 	// Stop permanently halts all pool operations.
 	Stop()
 }
 
+// RateLimit configures a token-bucket limit on how fast a group may
+// grant new leases, layered on top of (and independent of) its
+// concurrent Target.  This closes the gap where a group with a large
+// Target can instantly stampede a downstream on a reconnect storm.
+type RateLimit struct {
+	// Rate is the number of leases replenished per second.
+	Rate float64
+	// Burst is the maximum number of leases that can be granted in a
+	// single instant once the bucket is full.
+	Burst int
+}
+
 type pool struct {
 	sync.Mutex
 	leaseIDs *atomic.Uint64
@@ -63,9 +109,24 @@ type pool struct {
 	grantC   chan Lease
 	ctx      context.Context
 	cancel   context.CancelFunc
+	// global is non-nil once SetGlobal/NewPoolWithLimit has been used;
+	// it gates and arbitrates grants across all groups.
+	global *globalLimiter
 }
 
 func NewPool(ctx context.Context) Pool {
+	return newPool(ctx)
+}
+
+// NewPoolWithLimit creates a new pool whose groups are additionally
+// subject to a ceiling of max on the sum of their active leases.
+func NewPoolWithLimit(ctx context.Context, max uint64) Pool {
+	p := newPool(ctx)
+	p.global = &globalLimiter{target: max}
+	return p
+}
+
+func newPool(ctx context.Context) *pool {
 	ctx, cancel := context.WithCancel(ctx)
 	return &pool{
 		leaseIDs: atomic.NewUint64(0),
@@ -76,6 +137,61 @@ func NewPool(ctx context.Context) Pool {
 	}
 }
 
+// SetGlobal sets (or, with 0, clears) the ceiling on the sum of active
+// leases granted across all groups in the pool.
+func (p *pool) SetGlobal(max uint64) {
+	p.Lock()
+	defer p.Unlock()
+	if p.global == nil {
+		p.global = &globalLimiter{}
+	}
+	if p.global.unlimited() {
+		// leases (and reservations) granted while no ceiling was in
+		// effect were never counted against one; count them now.
+		var active uint64
+		for _, g := range p.groups {
+			active += g.loadCounts().Active
+			if g.isReserved() {
+				active++
+			}
+		}
+		p.global.mu.Lock()
+		p.global.active = active
+		p.global.mu.Unlock()
+	}
+	p.global.setTarget(max)
+	for _, g := range p.groups {
+		g.notify()
+	}
+}
+
+// SetTTL configures the group for key to grant LeaseWithDeadline leases
+// that are auto-released after ttl unless renewed.  A ttl of 0 disables
+// the behavior and reverts to ordinary, non-expiring leases.
+func (p *pool) SetTTL(key interface{}, ttl time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+	g, ok := p.groups[key]
+	if !ok {
+		p.start(key, 0)
+		g = p.groups[key]
+	}
+	g.setTTL(ttl)
+}
+
+// SetRate configures the group for key to grant new leases no faster
+// than limit allows.  A zero-value RateLimit disables throttling.
+func (p *pool) SetRate(key interface{}, limit RateLimit) {
+	p.Lock()
+	defer p.Unlock()
+	g, ok := p.groups[key]
+	if !ok {
+		p.start(key, 0)
+		g = p.groups[key]
+	}
+	g.setRate(limit)
+}
+
 // Acquire is the channel which must be received on to acquire
 // new leases.  Each lease acquired in this way *must* have its
 // Release method called when the lease is no longer needed.
@@ -87,10 +203,29 @@ func (p *pool) Acquire() <-chan Lease {
 func (p *pool) Get(key interface{}) Counts {
 	p.Lock()
 	defer p.Unlock()
-	if g, ok := p.groups[key]; ok {
-		return g.loadCounts()
+	g, ok := p.groups[key]
+	if !ok {
+		return Counts{}
+	}
+	counts := g.loadCounts()
+	counts.Grantable = p.grantable(counts)
+	return counts
+}
+
+// grantable reports how many additional leases counts' group could be
+// granted right now given the pool's remaining global capacity.
+func (p *pool) grantable(counts Counts) uint64 {
+	var local uint64
+	if counts.Active < counts.Target {
+		local = counts.Target - counts.Active
+	}
+	if p.global == nil {
+		return local
 	}
-	return Counts{}
+	if remaining := p.global.remaining(); remaining < local {
+		return remaining
+	}
+	return local
 }
 
 // Set sets the target for the specified key.  Automatically starts
@@ -124,6 +259,9 @@ func (p *pool) start(key interface{}, target uint64) {
 		key:      key,
 		grantC:   p.grantC,
 		notifyC:  notifyC,
+		pool:     p,
+		renewC:   make(chan renewal),
+		releaseC: make(chan uint64),
 		ctx:      ctx,
 		cancel:   cancel,
 	}
@@ -146,6 +284,59 @@ func (p *pool) Stop() {
 	p.cancel()
 }
 
+// notifyAll wakes every group's run loop, used to make groups re-check
+// grantability after a change in global state.
+func (p *pool) notifyAll() {
+	p.Lock()
+	defer p.Unlock()
+	for _, g := range p.groups {
+		g.notify()
+	}
+}
+
+// tryGrant reports whether g may grant a lease right now.  With no
+// global ceiling configured it always returns true.  Otherwise it picks,
+// among all groups currently wanting a lease (Active < Target), the one
+// with the largest deficit (Target-Active), breaking ties in favor of
+// whichever group was least recently granted one; g may only grant if it
+// is that group and a global slot is available.
+func (p *pool) tryGrant(g *group) bool {
+	if p.global == nil || p.global.unlimited() {
+		return true
+	}
+	p.Lock()
+	defer p.Unlock()
+	var best *group
+	var bestDeficit int64
+	for _, other := range p.groups {
+		if other.isReserved() {
+			// other already holds a claim on a global slot that it
+			// hasn't consumed yet; Active won't reflect that until it
+			// does, so treating it as still deficient here would let it
+			// monopolize every subsequent tryGrant call and starve every
+			// other group of the slots its own claim already accounts
+			// for.
+			continue
+		}
+		counts := other.loadCounts()
+		if counts.Active >= counts.Target {
+			continue
+		}
+		deficit := int64(counts.Target) - int64(counts.Active)
+		if best == nil || deficit > bestDeficit || (deficit == bestDeficit && other.lastGrant.Before(best.lastGrant)) {
+			best, bestDeficit = other, deficit
+		}
+	}
+	if best != g {
+		return false
+	}
+	if !p.global.reserve() {
+		return false
+	}
+	g.lastGrant = time.Now()
+	return true
+}
+
 // Counts holds the target and active counts for a
 // key/group.
 type Counts struct {
@@ -154,6 +345,66 @@ type Counts struct {
 	Target uint64
 	// Active is the current active lease count.
 	Active uint64
+	// Grantable is the number of additional leases that could be
+	// granted to this group right now, accounting for the pool's global
+	// ceiling (see SetGlobal) if one is set.  Equal to Target-Active
+	// otherwise.
+	Grantable uint64
+}
+
+// globalLimiter enforces an optional ceiling on the sum of active
+// leases across all groups in a pool, and is consulted by every group's
+// run loop before it offers a lease on the shared grant channel.
+type globalLimiter struct {
+	mu     sync.Mutex
+	target uint64
+	active uint64
+}
+
+// unlimited reports whether no ceiling is currently configured.
+func (l *globalLimiter) unlimited() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.target == 0
+}
+
+func (l *globalLimiter) setTarget(target uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.target = target
+}
+
+// reserve claims one of the global slots, returning false if the
+// ceiling is already saturated.
+func (l *globalLimiter) reserve() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.target != 0 && l.active >= l.target {
+		return false
+	}
+	l.active++
+	return true
+}
+
+// release gives back a previously reserved slot.
+func (l *globalLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.active > 0 {
+		l.active--
+	}
+}
+
+func (l *globalLimiter) remaining() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.target == 0 {
+		return math.MaxUint64
+	}
+	if l.active >= l.target {
+		return 0
+	}
+	return l.target - l.active
 }
 
 type group struct {
@@ -163,8 +414,126 @@ type group struct {
 	key      interface{}
 	grantC   chan Lease
 	notifyC  chan struct{}
-	ctx      context.Context
-	cancel   context.CancelFunc
+	pool     *pool
+	// lastGrant is the time this group last received a lease; used to
+	// break ties between equally-deficient groups under global pressure.
+	lastGrant time.Time
+	// reserved mirrors run's local reservation state: true whenever this
+	// group currently holds a global slot (via tryGrant) that it hasn't
+	// yet handed off as an actual lease grant.  Unlike Active, which only
+	// changes once a lease is actually taken off grantC, this is set the
+	// instant tryGrant succeeds, so other groups' tryGrant calls can see
+	// that the slot is already spoken for and compete for a different
+	// one instead of all piling onto the single highest-deficit group.
+	reserved bool
+	// ttl is the TTL newly-granted leases are given; 0 means leases
+	// don't expire.  Set via Pool.SetTTL.
+	ttl time.Duration
+	// renewC carries Renew requests from LeaseWithDeadline.Renew into
+	// this group's run loop, which owns the deadline heap.
+	renewC chan renewal
+	// releaseC carries lease IDs from leaseWithDeadline.Release into this
+	// group's run loop, so a manually-released lease's entry is dropped
+	// from deadlines/entries immediately instead of only on TTL expiry.
+	releaseC chan uint64
+	// rate is the token-bucket limit on how fast this group grants new
+	// leases; the zero value disables throttling.  Set via Pool.SetRate.
+	// tokens and lastRefill hold the bucket's state and are only ever
+	// touched by this group's own run loop.
+	rate       RateLimit
+	tokens     float64
+	lastRefill time.Time
+	ctx        context.Context
+	cancel     context.CancelFunc
+}
+
+// setRate updates the rate limit that this group grants new leases
+// under.
+func (g *group) setRate(rate RateLimit) {
+	g.cmu.Lock()
+	g.rate = rate
+	g.cmu.Unlock()
+	g.notify()
+}
+
+// loadRate loads the rate limit that this group currently grants new
+// leases under.
+func (g *group) loadRate() RateLimit {
+	g.cmu.Lock()
+	defer g.cmu.Unlock()
+	return g.rate
+}
+
+// refillAndPeek tops up the group's token bucket for elapsed time and
+// reports whether a token is currently available, without consuming it;
+// if not, wait is the duration until one will be.  Only called from the
+// group's own run loop, so it does not need cmu.
+func (g *group) refillAndPeek(rate RateLimit) (ok bool, wait time.Duration) {
+	if rate.Rate <= 0 {
+		return true, 0
+	}
+	// a Burst below 1 would otherwise clamp tokens to 0 forever and
+	// starve the group permanently; 1 is the smallest usable bucket.
+	burst := float64(rate.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	now := time.Now()
+	if g.lastRefill.IsZero() {
+		g.tokens = burst
+	} else {
+		g.tokens += now.Sub(g.lastRefill).Seconds() * rate.Rate
+		if g.tokens > burst {
+			g.tokens = burst
+		}
+	}
+	g.lastRefill = now
+	if g.tokens >= 1 {
+		return true, 0
+	}
+	return false, time.Duration((1 - g.tokens) / rate.Rate * float64(time.Second))
+}
+
+// takeToken consumes a previously-peeked token.  Only called from the
+// group's own run loop, immediately after an actual grant succeeds.
+func (g *group) takeToken(rate RateLimit) {
+	if rate.Rate > 0 {
+		g.tokens--
+	}
+}
+
+// setTTL updates the TTL that this group grants new leases with.
+func (g *group) setTTL(ttl time.Duration) {
+	g.cmu.Lock()
+	g.ttl = ttl
+	g.cmu.Unlock()
+	g.notify()
+}
+
+// loadTTL loads the TTL that this group currently grants new leases
+// with.
+func (g *group) loadTTL() time.Duration {
+	g.cmu.Lock()
+	defer g.cmu.Unlock()
+	return g.ttl
+}
+
+// setReserved records whether this group currently holds a claim on a
+// global slot (granted by tryGrant) that it hasn't yet handed off as an
+// actual lease grant.  Called by run at every transition of its local
+// reserved variable, so that other groups' tryGrant calls can see it.
+func (g *group) setReserved(reserved bool) {
+	g.cmu.Lock()
+	g.reserved = reserved
+	g.cmu.Unlock()
+}
+
+// isReserved reports whether this group currently holds an as-yet
+// unconsumed claim on a global slot.
+func (g *group) isReserved() bool {
+	g.cmu.Lock()
+	defer g.cmu.Unlock()
+	return g.reserved
 }
 
 // notify ensures that group is in a notified state.
@@ -190,20 +559,28 @@ func (g *group) loadCounts() Counts {
 // and ensures group is in the notified state.
 func (g *group) incrActive() Counts {
 	g.cmu.Lock()
-	defer g.cmu.Unlock()
 	g.counts.Active++
+	counts := g.counts
+	g.cmu.Unlock()
 	g.notify()
-	return g.counts
+	return counts
 }
 
 // decrActive decreases the active lease count by 1
-// and ensures group is in the notified state.
+// and ensures group is in the notified state.  If the pool has a global
+// ceiling, the freed slot is returned to it and every group is notified
+// so that one of them can claim it.
 func (g *group) decrActive() Counts {
 	g.cmu.Lock()
-	defer g.cmu.Unlock()
 	g.counts.Active--
+	counts := g.counts
+	g.cmu.Unlock()
 	g.notify()
-	return g.counts
+	if g.pool.global != nil {
+		g.pool.global.release()
+		g.pool.notifyAll()
+	}
+	return counts
 }
 
 // SetTarget sets the target lease count that the group should
@@ -217,41 +594,201 @@ func (g *group) SetTarget(target uint64) {
 
 func (g *group) run() {
 	var counts Counts
-	var nextLease *lease
+	var nextLease Lease
 	var grant chan Lease
+	// reserved tracks whether this group currently holds a claim granted
+	// by tryGrant (a no-op claim when the pool has no global ceiling).
+	// It is held across loop iterations rather than re-acquired every
+	// time, so that a single tryGrant call corresponds to exactly one
+	// eventual grant or release.
+	var reserved bool
+	// deadlines and entries track the TTL of every outstanding lease
+	// granted by this group, keyed by lease ID; only populated when the
+	// group's ttl is non-zero.  ttlTimer fires when the earliest
+	// deadline is reached.
+	var deadlines ttlHeap
+	entries := make(map[uint64]*ttlEntry)
+	var ttlTimer *time.Timer
+	var ttlTimerC <-chan time.Time
+	// rateTimer/rateTimerC fire once this group's token bucket has
+	// refilled enough to grant the next lease; only armed while a grant
+	// is being held up by the rate limit.
+	var rateTimer *time.Timer
+	var rateTimerC <-chan time.Time
+	// rate is reloaded once per iteration and reused for both the
+	// admission peek and, on success, token consumption, so the two
+	// agree even if SetRate races with this loop.
+	var rate RateLimit
 	for {
 		counts = g.loadCounts()
-		if counts.Active < counts.Target {
-			// we are in a "granting" state; ensure that the
-			// grant channel is non-nil, and initialize `nextLease`
-			// if it hasn't been already.
-			grant = g.grantC
+		switch {
+		case reserved && counts.Active >= counts.Target:
+			// our target dropped out from under us while we were
+			// waiting for a receiver; give the slot back.
+			g.releaseReservation()
+			reserved = false
+			g.setReserved(false)
+		case !reserved && counts.Active < counts.Target:
+			reserved = g.pool.tryGrant(g)
+			g.setReserved(reserved)
+		}
+		grant = nil
+		rateTimerC = nil
+		rate = g.loadRate()
+		if reserved {
+			// we are in a "granting" state; initialize `nextLease` if
+			// it hasn't been already, then only offer it on grantC once
+			// the group's rate limit also permits a grant.
 			if nextLease == nil {
-				nextLease = &lease{
-					group: g,
-					id:    g.leaseIDs.Add(1),
+				base := &lease{group: g, id: g.leaseIDs.Add(1)}
+				if ttl := g.loadTTL(); ttl > 0 {
+					nextLease = &leaseWithDeadline{lease: base, ttl: ttl}
+				} else {
+					nextLease = base
 				}
 			}
+			if ok, wait := g.refillAndPeek(rate); ok {
+				grant = g.grantC
+			} else {
+				rateTimer = resetTimer(rateTimer, wait)
+				rateTimerC = rateTimer.C
+			}
+		}
+		if len(deadlines) > 0 {
+			d := time.Until(deadlines[0].deadline)
+			if d < 0 {
+				d = 0
+			}
+			ttlTimer = resetTimer(ttlTimer, d)
+			ttlTimerC = ttlTimer.C
 		} else {
-			// we are not in a "granting" state, ensure that the
-			// grant channel is nil (prevents sends).
-			grant = nil
+			ttlTimerC = nil
 		}
 		select {
 		case grant <- nextLease:
 			g.incrActive()
+			g.takeToken(rate)
+			if lwd, ok := nextLease.(*leaseWithDeadline); ok {
+				e := &ttlEntry{deadline: time.Now().Add(lwd.ttl), id: lwd.id, lease: lwd.lease}
+				heap.Push(&deadlines, e)
+				entries[lwd.id] = e
+			}
 			nextLease = nil
+			reserved = false
+			g.setReserved(false)
 		case <-g.notifyC:
+		case req := <-g.renewC:
+			e, ok := entries[req.id]
+			if !ok {
+				req.errC <- trace.BadParameter("lease %v has already expired", req.id)
+				continue
+			}
+			e.deadline = time.Now().Add(g.loadTTL())
+			heap.Fix(&deadlines, e.idx)
+			req.errC <- nil
+		case id := <-g.releaseC:
+			if e, ok := entries[id]; ok {
+				heap.Remove(&deadlines, e.idx)
+				delete(entries, id)
+			}
+		case <-ttlTimerC:
+			e := heap.Pop(&deadlines).(*ttlEntry)
+			delete(entries, e.id)
+			e.lease.Release()
+		case <-rateTimerC:
 		case <-g.ctx.Done():
+			if reserved {
+				g.releaseReservation()
+				g.setReserved(false)
+			}
 			return
 		}
 	}
 }
 
+// releaseReservation gives back a claim previously granted by tryGrant
+// without having used it for an actual lease.
+func (g *group) releaseReservation() {
+	if g.pool.global != nil {
+		g.pool.global.release()
+		g.pool.notifyAll()
+	}
+}
+
+// resetTimer returns a *time.Timer that fires after d, reusing timer
+// when non-nil instead of allocating a new one.
+func resetTimer(timer *time.Timer, d time.Duration) *time.Timer {
+	if timer == nil {
+		return time.NewTimer(d)
+	}
+	if !timer.Stop() {
+		select {
+		case <-timer.C:
+		default:
+		}
+	}
+	timer.Reset(d)
+	return timer
+}
+
+// renewal is sent on a group's renewC by LeaseWithDeadline.Renew to push
+// a lease's deadline forward; errC receives the outcome.  The run loop
+// looks up the group's current TTL itself rather than trusting a value
+// carried on renewal, so a Renew always honors the TTL in effect at
+// renewal time, not the one in effect when the lease was granted.
+type renewal struct {
+	id   uint64
+	errC chan error
+}
+
+// ttlEntry is a single lease's position in a group's deadline heap.  It
+// stores the lease's plain (*lease) Release so TTL expiry calls the
+// base release logic directly rather than leaseWithDeadline's override,
+// which would otherwise try to notify this same run loop over releaseC
+// and deadlock against itself.
+type ttlEntry struct {
+	deadline time.Time
+	id       uint64
+	idx      int
+	lease    *lease
+}
+
+// ttlHeap is a container/heap of ttlEntry ordered by deadline, used by
+// group.run to efficiently find and expire the next lease to time out.
+type ttlHeap []*ttlEntry
+
+func (h ttlHeap) Len() int { return len(h) }
+
+func (h ttlHeap) Less(i, j int) bool { return h[i].deadline.Before(h[j].deadline) }
+
+func (h ttlHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].idx, h[j].idx = i, j
+}
+
+func (h *ttlHeap) Push(x interface{}) {
+	e := x.(*ttlEntry)
+	e.idx = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *ttlHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return e
+}
+
 type lease struct {
 	*group
 	id      uint64
 	relOnce sync.Once
+	// released is set once Release has actually run its body, so that
+	// leaseWithDeadline.Renew can tell a released lease apart from one
+	// that's merely not (yet) in entries for some other reason.
+	released atomic.Bool
 }
 
 func (l *lease) ID() uint64 {
@@ -264,10 +801,59 @@ func (l *lease) Key() interface{} {
 
 func (l *lease) Release() {
 	l.relOnce.Do(func() {
+		l.released.Store(true)
+		l.decrActive()
+	})
+}
+
+// leaseWithDeadline is a lease granted by a group configured with a TTL
+// (see Pool.SetTTL).  Its deadline is tracked by the owning group's run
+// loop, which releases it automatically if Renew is not called in time.
+type leaseWithDeadline struct {
+	*lease
+	ttl time.Duration
+}
+
+// Release relinquishes this lease.  In addition to the base lease's
+// Release, it tells the owning group's run loop to drop this lease's
+// entry from deadlines/entries, so a manual Release doesn't leave a
+// phantom entry behind that a racing Renew could keep reviving forever;
+// only that loop mutates deadlines/entries, so this is routed through
+// releaseC rather than touched directly.  A double-Release or a release
+// racing the group shutting down is a no-op, same as the base lease.
+func (l *leaseWithDeadline) Release() {
+	l.relOnce.Do(func() {
+		l.released.Store(true)
 		l.decrActive()
+		select {
+		case l.group.releaseC <- l.id:
+		case <-l.group.ctx.Done():
+		}
 	})
 }
 
+// Renew pushes this lease's deadline forward by the group's currently
+// configured TTL (see Pool.SetTTL), not the TTL in effect when the lease
+// was originally granted.  Returns an error if the lease has already
+// expired (and therefore been released) or its group has stopped.
+func (l *leaseWithDeadline) Renew() error {
+	if l.released.Load() {
+		return trace.BadParameter("lease %v has already been released", l.id)
+	}
+	errC := make(chan error, 1)
+	select {
+	case l.group.renewC <- renewal{id: l.id, errC: errC}:
+	case <-l.group.ctx.Done():
+		return trace.Wrap(l.group.ctx.Err())
+	}
+	select {
+	case err := <-errC:
+		return trace.Wrap(err)
+	case <-l.group.ctx.Done():
+		return trace.Wrap(l.group.ctx.Err())
+	}
+}
+
 type nopLease struct {
 	key interface{}
 }
@@ -0,0 +1,142 @@
+/*
+Copyright 2019 Gravitational, Inc.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package workpool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestGlobalFairnessWithUnconsumedReservation verifies that tryGrant does
+// not let one group monopolize the global ceiling just because it has a
+// much larger deficit.  A group's reservation is granted before it is
+// consumed (i.e. before anything reads the lease off Acquire), and that
+// gap must not make every other group's tryGrant call keep losing to the
+// same group forever.
+func TestGlobalFairnessWithUnconsumedReservation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := NewPoolWithLimit(ctx, 2).(*pool)
+	p.Set("big", 10)
+
+	// Nothing ever reads from p.Acquire() in this test, so "big" claims
+	// and holds a reservation without ever consuming it.
+	require.Eventually(t, func() bool {
+		p.Lock()
+		defer p.Unlock()
+		big := p.groups["big"]
+		return big != nil && big.isReserved()
+	}, time.Second, time.Millisecond, "expected big to hold an unconsumed reservation")
+
+	// "small" has a far smaller deficit than "big" (1 vs 10), which is
+	// exactly the case that used to make it lose tryGrant's fairness scan
+	// to "big" forever, even though "big" already has its one claim and
+	// a second global slot is free.
+	p.Set("small", 1)
+
+	require.Eventually(t, func() bool {
+		p.Lock()
+		defer p.Unlock()
+		small := p.groups["small"]
+		return small != nil && small.isReserved()
+	}, time.Second, time.Millisecond, "small's deficit-based tryGrant should not starve forever behind big's unconsumed reservation")
+}
+
+// TestRenewUsesCurrentTTL verifies that Renew pushes a lease's deadline
+// forward by the group's currently configured TTL, not the TTL that was
+// in effect when the lease was originally granted.
+func TestRenewUsesCurrentTTL(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := NewPool(ctx)
+	p.SetTTL("key", 50*time.Millisecond)
+	p.Set("key", 1)
+
+	lease := (<-p.Acquire()).(LeaseWithDeadline)
+	defer lease.Release()
+
+	p.SetTTL("key", time.Hour)
+	require.NoError(t, lease.Renew())
+
+	time.Sleep(150 * time.Millisecond)
+
+	// If Renew had applied the stale 50ms TTL captured at grant time, the
+	// lease would have expired and been auto-released by now, freeing the
+	// group to grant a replacement lease.
+	select {
+	case l2 := <-p.Acquire():
+		t.Fatalf("lease was auto-released and regranted (%v) despite Renew with an updated, much longer TTL", l2)
+	default:
+	}
+}
+
+// TestRateLimitThrottlesGrants verifies that a group's rate limit actually
+// paces out grants once its burst is exhausted, rather than only
+// preventing the Burst<1 stall fixed earlier.
+func TestRateLimitThrottlesGrants(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := NewPool(ctx)
+	p.SetRate("key", RateLimit{Rate: 20, Burst: 1})
+	p.Set("key", 3)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		<-p.Acquire()
+	}
+	elapsed := time.Since(start)
+
+	// The burst of 1 lets the first grant through immediately; the other
+	// two must each wait for the bucket to refill a token at 20/sec
+	// (50ms apart), so three grants should take comfortably more than
+	// 50ms, not come out back-to-back.
+	require.GreaterOrEqual(t, elapsed, 80*time.Millisecond, "rate limit should throttle grants beyond the burst, not hand them out immediately")
+}
+
+// TestReleaseThenRenewErrors verifies that releasing a TTL lease drops
+// its deadline/entries bookkeeping immediately, instead of leaving it to
+// only be cleaned up by TTL expiry.  Without that, a heartbeat goroutine
+// racing an early Release (exactly the caller bug this feature exists to
+// guard against) could keep calling Renew successfully on a released
+// lease forever, reviving a phantom entry that never gets cleaned up.
+func TestReleaseThenRenewErrors(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	p := NewPool(ctx)
+	p.SetTTL("key", time.Hour)
+	p.Set("key", 1)
+
+	lease := (<-p.Acquire()).(LeaseWithDeadline)
+	lease.Release()
+
+	require.Error(t, lease.Renew(), "Renew after Release must error, not silently succeed")
+
+	// The group should be free to grant a fresh lease for the now-vacated
+	// slot, and that lease's own Renew should work normally - proving the
+	// released lease's bookkeeping was actually dropped rather than left
+	// dangling behind it.
+	lease2 := (<-p.Acquire()).(LeaseWithDeadline)
+	defer lease2.Release()
+	require.NoError(t, lease2.Renew())
+}